@@ -1,32 +1,83 @@
 package matchers
 
-import (
-	"bytes"
-	"encoding/binary"
-)
-
 var (
 	TypeDoc  = newType("doc", "application/msword")
 	TypeDocx = newType("docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document")
+	TypeDocm = newType("docm", "application/vnd.ms-word.document.macroEnabled.12")
+	TypeDotx = newType("dotx", "application/vnd.openxmlformats-officedocument.wordprocessingml.template")
 	TypeXls  = newType("xls", "application/vnd.ms-excel")
 	TypeXlsx = newType("xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	TypeXlsm = newType("xlsm", "application/vnd.ms-excel.sheet.macroEnabled.12")
+	TypeXltx = newType("xltx", "application/vnd.openxmlformats-officedocument.spreadsheetml.template")
 	TypePpt  = newType("ppt", "application/vnd.ms-powerpoint")
 	TypePptx = newType("pptx", "application/vnd.openxmlformats-officedocument.presentationml.presentation")
+	TypePptm = newType("pptm", "application/vnd.ms-powerpoint.presentation.macroEnabled.12")
+	TypePotx = newType("potx", "application/vnd.openxmlformats-officedocument.presentationml.template")
+	TypeVsdx = newType("vsdx", "application/vnd.ms-visio.drawing")
+	TypeXps  = newType("xps", "application/vnd.ms-xpsdocument")
 	TypeOdp  = newType("odp", "application/vnd.oasis.opendocument.presentation")
 	TypeOds  = newType("ods", "application/vnd.oasis.opendocument.spreadsheet")
 	TypeOdt  = newType("odt", "application/vnd.oasis.opendocument.text")
+	TypeOdg  = newType("odg", "application/vnd.oasis.opendocument.graphics")
+	TypeOdf  = newType("odf", "application/vnd.oasis.opendocument.formula")
+	TypeOdc  = newType("odc", "application/vnd.oasis.opendocument.chart")
+	TypeOdi  = newType("odi", "application/vnd.oasis.opendocument.image")
+	TypeOdm  = newType("odm", "application/vnd.oasis.opendocument.text-master")
+	TypeOtt  = newType("ott", "application/vnd.oasis.opendocument.text-template")
+	TypeOts  = newType("ots", "application/vnd.oasis.opendocument.spreadsheet-template")
+	TypeOtp  = newType("otp", "application/vnd.oasis.opendocument.presentation-template")
+	TypeOtg  = newType("otg", "application/vnd.oasis.opendocument.graphics-template")
+	TypeMsg  = newType("msg", "application/vnd.ms-outlook")
+	TypeMsi  = newType("msi", "application/x-msi")
+	TypePub  = newType("pub", "application/vnd.ms-publisher")
 )
 
 var Document = Map{
 	TypeDoc:  Doc,
 	TypeDocx: Docx,
+	TypeDocm: Docm,
+	TypeDotx: Dotx,
 	TypeXls:  Xls,
 	TypeXlsx: Xlsx,
+	TypeXlsm: Xlsm,
+	TypeXltx: Xltx,
 	TypePpt:  Ppt,
 	TypePptx: Pptx,
+	TypePptm: Pptm,
+	TypePotx: Potx,
+	TypeVsdx: Vsdx,
+	TypeXps:  Xps,
 	TypeOdp:  Odp,
 	TypeOds:  Ods,
 	TypeOdt:  Odt,
+	TypeMsg:  Msg,
+	TypeMsi:  Msi,
+	TypePub:  Pub,
+}
+
+// odfTypes are the OpenDocument formats whose detection is pure data: since
+// checkOdf only ever varies by the mimetype it checks for, adding one of
+// these doesn't need a hand-written matcher function (unlike Odp/Ods/Odt
+// above, kept as named functions for their existing external callers).
+var odfTypes = []Type{
+	TypeOdg,
+	TypeOdf,
+	TypeOdc,
+	TypeOdi,
+	TypeOdm,
+	TypeOtt,
+	TypeOts,
+	TypeOtp,
+	TypeOtg,
+}
+
+func init() {
+	for _, t := range odfTypes {
+		mime := t.MIME.Value
+		Document[t] = func(buf []byte) bool {
+			return checkOdf(buf, mime)
+		}
+	}
 }
 
 type docType int
@@ -34,27 +85,34 @@ type docType int
 const (
 	TYPE_DOC docType = iota
 	TYPE_DOCX
+	TYPE_DOCM
+	TYPE_DOTX
 	TYPE_XLS
 	TYPE_XLSX
+	TYPE_XLSM
+	TYPE_XLTX
 	TYPE_PPT
 	TYPE_PPTX
+	TYPE_PPTM
+	TYPE_POTX
+	TYPE_VSDX
+	TYPE_XPS
 	TYPE_OOXML
 	TYPE_ODP
 	TYPE_ODS
 	TYPE_ODT
+	TYPE_MSG
+	TYPE_MSI
+	TYPE_PUB
 )
 
-//reference: https://bz.apache.org/ooo/show_bug.cgi?id=111457
+// Doc, Xls and Ppt are all legacy CFB (OLE2) containers; oleType tells them
+// apart (along with Msg/Msi/Pub below) by walking the container's directory
+// stream instead of peeking at a fixed byte offset, which breaks for large
+// documents whose root directory doesn't land in sector 1.
 func Doc(buf []byte) bool {
-	if len(buf) > 513 {
-		return buf[0] == 0xD0 && buf[1] == 0xCF &&
-			buf[2] == 0x11 && buf[3] == 0xE0 &&
-			buf[512] == 0xEC && buf[513] == 0xA5
-	} else {
-		return len(buf) > 3 &&
-			buf[0] == 0xD0 && buf[1] == 0xCF &&
-			buf[2] == 0x11 && buf[3] == 0xE0
-	}
+	typ, ok := oleType(buf)
+	return ok && typ == TYPE_DOC
 }
 
 func Docx(buf []byte) bool {
@@ -62,16 +120,19 @@ func Docx(buf []byte) bool {
 	return ok && typ == TYPE_DOCX
 }
 
+func Docm(buf []byte) bool {
+	typ, ok := msooxml(buf)
+	return ok && typ == TYPE_DOCM
+}
+
+func Dotx(buf []byte) bool {
+	typ, ok := msooxml(buf)
+	return ok && typ == TYPE_DOTX
+}
+
 func Xls(buf []byte) bool {
-	if len(buf) > 513 {
-		return buf[0] == 0xD0 && buf[1] == 0xCF &&
-			buf[2] == 0x11 && buf[3] == 0xE0 &&
-			buf[512] == 0x09 && buf[513] == 0x08
-	} else {
-		return len(buf) > 3 &&
-			buf[0] == 0xD0 && buf[1] == 0xCF &&
-			buf[2] == 0x11 && buf[3] == 0xE0
-	}
+	typ, ok := oleType(buf)
+	return ok && typ == TYPE_XLS
 }
 
 func Xlsx(buf []byte) bool {
@@ -79,16 +140,34 @@ func Xlsx(buf []byte) bool {
 	return ok && typ == TYPE_XLSX
 }
 
+func Xlsm(buf []byte) bool {
+	typ, ok := msooxml(buf)
+	return ok && typ == TYPE_XLSM
+}
+
+func Xltx(buf []byte) bool {
+	typ, ok := msooxml(buf)
+	return ok && typ == TYPE_XLTX
+}
+
 func Ppt(buf []byte) bool {
-	if len(buf) > 513 {
-		return buf[0] == 0xD0 && buf[1] == 0xCF &&
-			buf[2] == 0x11 && buf[3] == 0xE0 &&
-			buf[512] == 0xA0 && buf[513] == 0x46
-	} else {
-		return len(buf) > 3 &&
-			buf[0] == 0xD0 && buf[1] == 0xCF &&
-			buf[2] == 0x11 && buf[3] == 0xE0
-	}
+	typ, ok := oleType(buf)
+	return ok && typ == TYPE_PPT
+}
+
+func Msg(buf []byte) bool {
+	typ, ok := oleType(buf)
+	return ok && typ == TYPE_MSG
+}
+
+func Msi(buf []byte) bool {
+	typ, ok := oleType(buf)
+	return ok && typ == TYPE_MSI
+}
+
+func Pub(buf []byte) bool {
+	typ, ok := oleType(buf)
+	return ok && typ == TYPE_PUB
 }
 
 func Pptx(buf []byte) bool {
@@ -96,62 +175,92 @@ func Pptx(buf []byte) bool {
 	return ok && typ == TYPE_PPTX
 }
 
-func msooxml(buf []byte) (typ docType, found bool) {
-	signature := []byte{'P', 'K', 0x03, 0x04}
+func Pptm(buf []byte) bool {
+	typ, ok := msooxml(buf)
+	return ok && typ == TYPE_PPTM
+}
 
-	// start by checking for ZIP local file header signature
-	if ok := compareBytes(buf, signature, 0); !ok {
-		return
-	}
+func Potx(buf []byte) bool {
+	typ, ok := msooxml(buf)
+	return ok && typ == TYPE_POTX
+}
 
-	// make sure the first file is correct
-	if v, ok := checkMSOoml(buf, 0x1E); ok {
-		return v, ok
-	}
+func Vsdx(buf []byte) bool {
+	typ, ok := msooxml(buf)
+	return ok && typ == TYPE_VSDX
+}
 
-	if !compareBytes(buf, []byte("[Content_Types].xml"), 0x1E) &&
-		!compareBytes(buf, []byte("_rels/.rels"), 0x1E) &&
-		!compareBytes(buf, []byte("docProps"), 0x1E) {
-		return
-	}
+func Xps(buf []byte) bool {
+	typ, ok := msooxml(buf)
+	return ok && typ == TYPE_XPS
+}
 
-	// skip to the second local file header
-	// since some documents include a 520-byte extra field following the file
-	// header, we need to scan for the next header
-	startOffset := int(binary.LittleEndian.Uint32(buf[18:22]) + 49)
-	idx := search(buf, startOffset, 6000)
-	if idx == -1 {
+// msooxml determines the OOXML document type, if any, by reading the ZIP
+// central directory for the well-known part that identifies each format.
+// Unlike scanning local file headers in order, this isn't thrown off by
+// large extra fields, a central directory located via a ZIP64 locator/
+// record, or an archive tool reordering entries.
+// Visio and XPS have main parts of their own. Macro-enabled/template
+// variants share their main part name with their plain counterpart, so
+// those are told apart by the vbaProject.bin stream (macros) or a peek at
+// [Content_Types].xml (templates).
+//
+// Microsoft Project isn't handled here: .mpp never moved to an OOXML
+// package, even in current Project versions, so there's no ZIP part to
+// look for. It would need CFB-based detection alongside Doc/Xls/Ppt in
+// oleType instead, which needs its own root-storage CLSID or stream-name
+// signature before it can be added.
+func msooxml(buf []byte) (typ docType, found bool) {
+	if !compareBytes(buf, []byte{'P', 'K', 0x03, 0x04}, 0) {
 		return
 	}
 
-	// now skip to the *third* local file header; again, we need to scan due to a
-	// 520-byte extra field following the file header
-	startOffset += idx + 4 + 26
-	idx = search(buf, startOffset, 6000)
-	if idx == -1 {
-		return
+	switch {
+	case zipContains(buf, []byte("FixedDocSeq.fdseq")):
+		return TYPE_XPS, true
+	case zipContains(buf, []byte("visio/document.xml")):
+		return TYPE_VSDX, true
+	case zipContains(buf, []byte("word/document.xml")):
+		return wordSubtype(buf), true
+	case zipContains(buf, []byte("ppt/presentation.xml")):
+		return pptSubtype(buf), true
+	case zipContains(buf, []byte("xl/workbook.xml")):
+		return xlsxSubtype(buf), true
 	}
 
-	// and check the subdirectory name to determine which type of OOXML
-	// file we have.  Correct the mimetype with the registered ones:
-	// http://technet.microsoft.com/en-us/library/cc179224.aspx
-	startOffset += idx + 4 + 26
-	if typ, ok := checkMSOoml(buf, startOffset); ok {
-		return typ, ok
+	return
+}
+
+func wordSubtype(buf []byte) docType {
+	switch {
+	case zipContains(buf, []byte("word/vbaProject.bin")):
+		return TYPE_DOCM
+	case contentTypeContains(buf, "wordprocessingml.template"):
+		return TYPE_DOTX
+	default:
+		return TYPE_DOCX
 	}
+}
 
-	// OpenOffice/Libreoffice orders ZIP entry differently, so check the 4th file
-	startOffset += 26
-	idx = search(buf, startOffset, 6000)
-	if idx == -1 {
-		return TYPE_OOXML, true
+func xlsxSubtype(buf []byte) docType {
+	switch {
+	case zipContains(buf, []byte("xl/vbaProject.bin")):
+		return TYPE_XLSM
+	case contentTypeContains(buf, "spreadsheetml.template"):
+		return TYPE_XLTX
+	default:
+		return TYPE_XLSX
 	}
+}
 
-	startOffset += idx + 4 + 26
-	if typ, ok := checkMSOoml(buf, startOffset); ok {
-		return typ, ok
-	} else {
-		return TYPE_OOXML, true
+func pptSubtype(buf []byte) docType {
+	switch {
+	case zipContains(buf, []byte("ppt/vbaProject.bin")):
+		return TYPE_PPTM
+	case contentTypeContains(buf, "presentationml.template"):
+		return TYPE_POTX
+	default:
+		return TYPE_PPTX
 	}
 }
 
@@ -172,39 +281,6 @@ func compareBytes(slice, subSlice []byte, startOffset int) bool {
 	return true
 }
 
-func checkMSOoml(buf []byte, offset int) (typ docType, ok bool) {
-	ok = true
-
-	switch {
-	case compareBytes(buf, []byte("word/"), offset):
-		typ = TYPE_DOCX
-	case compareBytes(buf, []byte("ppt/"), offset):
-		typ = TYPE_PPTX
-	case compareBytes(buf, []byte("xl/"), offset):
-		typ = TYPE_XLSX
-	default:
-		ok = false
-	}
-
-	return
-}
-
-func search(buf []byte, start, rangeNum int) int {
-	length := len(buf)
-	end := start + rangeNum
-	signature := []byte{'P', 'K', 0x03, 0x04}
-
-	if end > length {
-		end = length
-	}
-
-	if start >= end {
-		return -1
-	}
-
-	return bytes.Index(buf[start:end], signature)
-}
-
 func Odp(buf []byte) bool {
 	return checkOdf(buf, TypeOdp.MIME.Value)
 }
@@ -220,35 +296,12 @@ func Odt(buf []byte) bool {
 // https://en.wikipedia.org/wiki/OpenDocument_technical_specification
 // https://en.wikipedia.org/wiki/ZIP_(file_format)
 func checkOdf(buf []byte, mimetype string) bool {
-	if 38+len(mimetype) >= len(buf) {
-		return false
-	}
-	// Perform all byte checks first for better performance
-	// Check ZIP start
-	if buf[0] != 'P' || buf[1] != 'K' || buf[2] != 3 || buf[3] != 4 {
-		return false
-	}
-	// Now check the first file data
-	// Compression method: not compressed
-	if buf[8] != 0 || buf[9] != 0 {
-		return false
-	}
-	// Filename length must be 8 for "mimetype"
-	if buf[26] != 8 || buf[27] != 0 {
-		return false
-	}
-	// Check the file contents sizes
-	if int(buf[18]) != len(mimetype) ||
-		buf[19] != 0 || buf[20] != 0 || buf[21] != 0 ||
-		int(buf[22]) != len(mimetype) ||
-		buf[23] != 0 || buf[24] != 0 || buf[25] != 0 {
-		return false
-	}
-	// No extra field (for data offset below)
-	if buf[28] != 0 || buf[29] != 0 {
-		return false
-	}
-	// Finally check the file name and contents
-	return string(buf[30:38]) == "mimetype" &&
-		string(buf[38:38+len(mimetype)]) == mimetype
+	// Resolve "mimetype" through the central directory's localHeaderOffset,
+	// the way zipReadFile does, rather than assuming it's ZIP entry zero at
+	// buf[0:]: that assumption breaks as soon as the entry has so much as a
+	// one-byte extra field (e.g. an extended-timestamp field some writers
+	// add), which shifts its data past the fixed offsets a flat read would
+	// expect.
+	data, ok := zipReadFile(buf, []byte("mimetype"))
+	return ok && string(data) == mimetype
 }
@@ -0,0 +1,185 @@
+package matchers
+
+import (
+	"bytes"
+	"io"
+)
+
+// DocumentReader mirrors Document, but for the subset of formats that can
+// be identified from their ZIP central directory and the small entries it
+// points to: callers with an io.ReaderAt onto a large file (e.g. an
+// *os.File) can use it to sniff the type without reading the whole file
+// into memory first. The CFB-based Doc/Xls/Ppt/Msg/Msi/Pub aren't included
+// since their container doesn't support the same O(1) lookup.
+var DocumentReader = MapReader{
+	TypeDocx: DocxReader,
+	TypeDocm: DocmReader,
+	TypeDotx: DotxReader,
+	TypeXlsx: XlsxReader,
+	TypeXlsm: XlsmReader,
+	TypeXltx: XltxReader,
+	TypePptx: PptxReader,
+	TypePptm: PptmReader,
+	TypePotx: PotxReader,
+	TypeVsdx: VsdxReader,
+	TypeXps:  XpsReader,
+	TypeOdp:  OdpReader,
+	TypeOds:  OdsReader,
+	TypeOdt:  OdtReader,
+}
+
+// odfTypes (declared in document.go, alongside Document's own registration)
+// are registered here the same data-driven way, since checkOdfReader, like
+// checkOdf, only ever varies by the mimetype it checks for.
+func init() {
+	for _, t := range odfTypes {
+		mime := t.MIME.Value
+		DocumentReader[t] = func(r io.ReaderAt, size int64) bool {
+			return checkOdfReader(r, size, mime)
+		}
+	}
+}
+
+func DocxReader(r io.ReaderAt, size int64) bool {
+	typ, ok := msooxmlReader(r, size)
+	return ok && typ == TYPE_DOCX
+}
+
+func DocmReader(r io.ReaderAt, size int64) bool {
+	typ, ok := msooxmlReader(r, size)
+	return ok && typ == TYPE_DOCM
+}
+
+func DotxReader(r io.ReaderAt, size int64) bool {
+	typ, ok := msooxmlReader(r, size)
+	return ok && typ == TYPE_DOTX
+}
+
+func XlsxReader(r io.ReaderAt, size int64) bool {
+	typ, ok := msooxmlReader(r, size)
+	return ok && typ == TYPE_XLSX
+}
+
+func XlsmReader(r io.ReaderAt, size int64) bool {
+	typ, ok := msooxmlReader(r, size)
+	return ok && typ == TYPE_XLSM
+}
+
+func XltxReader(r io.ReaderAt, size int64) bool {
+	typ, ok := msooxmlReader(r, size)
+	return ok && typ == TYPE_XLTX
+}
+
+func PptxReader(r io.ReaderAt, size int64) bool {
+	typ, ok := msooxmlReader(r, size)
+	return ok && typ == TYPE_PPTX
+}
+
+func PptmReader(r io.ReaderAt, size int64) bool {
+	typ, ok := msooxmlReader(r, size)
+	return ok && typ == TYPE_PPTM
+}
+
+func PotxReader(r io.ReaderAt, size int64) bool {
+	typ, ok := msooxmlReader(r, size)
+	return ok && typ == TYPE_POTX
+}
+
+func VsdxReader(r io.ReaderAt, size int64) bool {
+	typ, ok := msooxmlReader(r, size)
+	return ok && typ == TYPE_VSDX
+}
+
+func XpsReader(r io.ReaderAt, size int64) bool {
+	typ, ok := msooxmlReader(r, size)
+	return ok && typ == TYPE_XPS
+}
+
+// msooxmlReader is msooxml for an io.ReaderAt: it reads the leading ZIP
+// signature, the central directory and, where needed, the
+// [Content_Types].xml entry, regardless of the archive's overall size.
+// Macro-enabled/template variants are told apart the same way msooxml does
+// it: by the vbaProject.bin stream or a peek at [Content_Types].xml, both
+// read through the ReaderAt-based zip helpers.
+func msooxmlReader(r io.ReaderAt, size int64) (typ docType, found bool) {
+	if size < 4 {
+		return
+	}
+
+	sig := make([]byte, 4)
+	if _, err := r.ReadAt(sig, 0); err != nil && err != io.EOF {
+		return
+	}
+	if !bytes.Equal(sig, []byte{'P', 'K', 0x03, 0x04}) {
+		return
+	}
+
+	switch {
+	case zipContainsReader(r, size, []byte("FixedDocSeq.fdseq")):
+		return TYPE_XPS, true
+	case zipContainsReader(r, size, []byte("visio/document.xml")):
+		return TYPE_VSDX, true
+	case zipContainsReader(r, size, []byte("word/document.xml")):
+		return wordSubtypeReader(r, size), true
+	case zipContainsReader(r, size, []byte("ppt/presentation.xml")):
+		return pptSubtypeReader(r, size), true
+	case zipContainsReader(r, size, []byte("xl/workbook.xml")):
+		return xlsxSubtypeReader(r, size), true
+	}
+
+	return
+}
+
+func wordSubtypeReader(r io.ReaderAt, size int64) docType {
+	switch {
+	case zipContainsReader(r, size, []byte("word/vbaProject.bin")):
+		return TYPE_DOCM
+	case contentTypeContainsReader(r, size, "wordprocessingml.template"):
+		return TYPE_DOTX
+	default:
+		return TYPE_DOCX
+	}
+}
+
+func xlsxSubtypeReader(r io.ReaderAt, size int64) docType {
+	switch {
+	case zipContainsReader(r, size, []byte("xl/vbaProject.bin")):
+		return TYPE_XLSM
+	case contentTypeContainsReader(r, size, "spreadsheetml.template"):
+		return TYPE_XLTX
+	default:
+		return TYPE_XLSX
+	}
+}
+
+func pptSubtypeReader(r io.ReaderAt, size int64) docType {
+	switch {
+	case zipContainsReader(r, size, []byte("ppt/vbaProject.bin")):
+		return TYPE_PPTM
+	case contentTypeContainsReader(r, size, "presentationml.template"):
+		return TYPE_POTX
+	default:
+		return TYPE_PPTX
+	}
+}
+
+func OdpReader(r io.ReaderAt, size int64) bool {
+	return checkOdfReader(r, size, TypeOdp.MIME.Value)
+}
+
+func OdsReader(r io.ReaderAt, size int64) bool {
+	return checkOdfReader(r, size, TypeOds.MIME.Value)
+}
+
+func OdtReader(r io.ReaderAt, size int64) bool {
+	return checkOdfReader(r, size, TypeOdt.MIME.Value)
+}
+
+// checkOdfReader is checkOdf for an io.ReaderAt: it resolves "mimetype"
+// through the central directory's localHeaderOffset instead of assuming it
+// sits at offset 0, so an extra field on that entry can't shift its data
+// out from under a fixed-offset read.
+func checkOdfReader(r io.ReaderAt, size int64, mimetype string) bool {
+	data, ok := zipReadFileReader(r, size, []byte("mimetype"))
+	return ok && string(data) == mimetype
+}
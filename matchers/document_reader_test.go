@@ -0,0 +1,175 @@
+package matchers
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestZipContainsReader(t *testing.T) {
+	archive := buildZip(t, []testZipEntry{
+		{name: "word/document.xml", data: []byte("<xml/>"), method: 8},
+	})
+	r := newSizedReader(archive)
+
+	if !zipContainsReader(r, int64(len(archive)), []byte("word/document.xml")) {
+		t.Fatal("expected entry to be found via the central directory")
+	}
+	if zipContainsReader(r, int64(len(archive)), []byte("missing")) {
+		t.Fatal("unexpected match for an absent entry")
+	}
+}
+
+func TestZipReadFileReaderDataDescriptor(t *testing.T) {
+	want := []byte(`<Types><Override ContentType="...wordprocessingml.template..."/></Types>`)
+	archive := buildZip(t, []testZipEntry{
+		{name: "[Content_Types].xml", data: want, method: 8, dataDescriptor: true},
+	})
+	r := newSizedReader(archive)
+
+	got, ok := zipReadFileReader(r, int64(len(archive)), []byte("[Content_Types].xml"))
+	if !ok {
+		t.Fatal("expected to read an entry written with the data-descriptor flag set")
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestMsooxmlReaderSubtypes(t *testing.T) {
+	docBase := []byte("<w:document/>")
+
+	cases := []struct {
+		name    string
+		entries []testZipEntry
+		matches ReaderMatcher
+		want    bool
+	}{
+		{
+			name:    "docx",
+			entries: []testZipEntry{{name: "word/document.xml", data: docBase, method: 0}},
+			matches: DocxReader,
+			want:    true,
+		},
+		{
+			name: "docm via vbaProject.bin",
+			entries: []testZipEntry{
+				{name: "word/document.xml", data: docBase, method: 0},
+				{name: "word/vbaProject.bin", data: []byte("macro"), method: 0},
+			},
+			matches: DocmReader,
+			want:    true,
+		},
+		{
+			name: "dotx via content types",
+			entries: []testZipEntry{
+				{name: "word/document.xml", data: docBase, method: 0},
+				{name: "[Content_Types].xml", data: []byte("wordprocessingml.template"), method: 8},
+			},
+			matches: DotxReader,
+			want:    true,
+		},
+		{
+			name: "xlsm via vbaProject.bin",
+			entries: []testZipEntry{
+				{name: "xl/workbook.xml", data: []byte("<workbook/>"), method: 0},
+				{name: "xl/vbaProject.bin", data: []byte("macro"), method: 0},
+			},
+			matches: XlsmReader,
+			want:    true,
+		},
+		{
+			name: "pptm via vbaProject.bin",
+			entries: []testZipEntry{
+				{name: "ppt/presentation.xml", data: []byte("<presentation/>"), method: 0},
+				{name: "ppt/vbaProject.bin", data: []byte("macro"), method: 0},
+			},
+			matches: PptmReader,
+			want:    true,
+		},
+		{
+			name:    "docm reader rejects a plain docx",
+			entries: []testZipEntry{{name: "word/document.xml", data: docBase, method: 0}},
+			matches: DocmReader,
+			want:    false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			archive := buildZip(t, c.entries)
+			r := newSizedReader(archive)
+
+			if got := c.matches(r, int64(len(archive))); got != c.want {
+				t.Fatalf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestCheckOdfReader(t *testing.T) {
+	mimetype := TypeOdt.MIME.Value
+	archive := buildZip(t, []testZipEntry{
+		{name: "mimetype", data: []byte(mimetype), method: 0},
+	})
+	r := newSizedReader(archive)
+
+	if !OdtReader(r, int64(len(archive))) {
+		t.Fatal("expected OdtReader to match a well-formed ODF mimetype entry")
+	}
+	if OdsReader(r, int64(len(archive))) {
+		t.Fatal("OdsReader matched an odt mimetype")
+	}
+}
+
+// TestCheckOdfReaderMimetypeEntryWithExtraField is the io.ReaderAt
+// counterpart of TestCheckOdfMimetypeEntryWithExtraField.
+func TestCheckOdfReaderMimetypeEntryWithExtraField(t *testing.T) {
+	mimetype := TypeOdt.MIME.Value
+	archive := buildZip(t, []testZipEntry{
+		{name: "mimetype", data: []byte(mimetype), method: 0, extraField: []byte("123456789")},
+	})
+	r := newSizedReader(archive)
+
+	if !OdtReader(r, int64(len(archive))) {
+		t.Fatal("expected OdtReader to match a mimetype entry with a leading extra field")
+	}
+}
+
+func TestDocumentReaderHasDataDrivenOdfTypes(t *testing.T) {
+	mimetype := TypeOdg.MIME.Value
+	archive := buildZip(t, []testZipEntry{
+		{name: "mimetype", data: []byte(mimetype), method: 0},
+	})
+	r := newSizedReader(archive)
+
+	matcher, ok := DocumentReader[TypeOdg]
+	if !ok {
+		t.Fatal("expected DocumentReader to have an entry for TypeOdg")
+	}
+	if !matcher(r, int64(len(archive))) {
+		t.Fatal("expected the TypeOdg matcher to recognize its own mimetype")
+	}
+}
+
+func TestMatchReaderAt(t *testing.T) {
+	archive := buildZip(t, []testZipEntry{
+		{name: "word/document.xml", data: []byte("<w:document/>"), method: 0},
+	})
+	r := newSizedReader(archive)
+
+	typ, ok := MatchReaderAt(r, int64(len(archive)), DocumentReader)
+	if !ok || typ != TypeDocx {
+		t.Fatalf("got (%v, %v), want (TypeDocx, true)", typ, ok)
+	}
+}
+
+func TestMatchReader(t *testing.T) {
+	archive := buildZip(t, []testZipEntry{
+		{name: "word/document.xml", data: []byte("<w:document/>"), method: 0},
+	})
+
+	typ, ok := MatchReader(bytes.NewReader(archive), Document)
+	if !ok || typ != TypeDocx {
+		t.Fatalf("got (%v, %v), want (TypeDocx, true)", typ, ok)
+	}
+}
@@ -0,0 +1,33 @@
+package matchers
+
+import "testing"
+
+func TestCheckOdf(t *testing.T) {
+	mimetype := TypeOdt.MIME.Value
+	archive := buildZip(t, []testZipEntry{
+		{name: "mimetype", data: []byte(mimetype), method: 0},
+	})
+
+	if !Odt(archive) {
+		t.Fatal("expected Odt to match a well-formed ODF mimetype entry")
+	}
+	if Ods(archive) {
+		t.Fatal("Ods matched an odt mimetype")
+	}
+}
+
+// TestCheckOdfMimetypeEntryWithExtraField guards against regressing into
+// reading "mimetype"'s data from buf[0:] directly: an extra field in the
+// entry's own local header (here nine bytes, the size of an
+// extended-timestamp field some writers add) shifts its data past any
+// offset computed that way.
+func TestCheckOdfMimetypeEntryWithExtraField(t *testing.T) {
+	mimetype := TypeOdt.MIME.Value
+	archive := buildZip(t, []testZipEntry{
+		{name: "mimetype", data: []byte(mimetype), method: 0, extraField: []byte("123456789")},
+	})
+
+	if !Odt(archive) {
+		t.Fatal("expected Odt to match a mimetype entry with a leading extra field")
+	}
+}
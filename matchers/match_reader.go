@@ -0,0 +1,45 @@
+package matchers
+
+import "io"
+
+// matchReaderHeaderSize is how much of r is buffered for MatchReader's
+// []byte-based matchers, mirroring the sniff-buffer sizes callers
+// typically use with the plain Map-based Match.
+const matchReaderHeaderSize = 8192
+
+// MatchReader runs m's matchers against r, reading at most
+// matchReaderHeaderSize bytes into memory first. It's the io.Reader
+// counterpart to matching against a pre-loaded []byte: useful when r isn't
+// seekable (so MatchReaderAt's random access isn't available) and reading
+// the whole input up front isn't acceptable.
+func MatchReader(r io.Reader, m Map) (Type, bool) {
+	buf := make([]byte, matchReaderHeaderSize)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return Type{}, false
+	}
+	buf = buf[:n]
+
+	for typ, matcher := range m {
+		if matcher(buf) {
+			return typ, true
+		}
+	}
+
+	return Type{}, false
+}
+
+// MatchReaderAt runs m's matchers against r, a random-access view onto a
+// file of the given size. Unlike MatchReader it isn't limited to the first
+// matchReaderHeaderSize bytes, yet never reads the whole file into memory:
+// formats identified by their ZIP central directory (e.g. the OOXML/ODF
+// matchers in DocumentReader) stay O(1) in I/O regardless of file size.
+func MatchReaderAt(r io.ReaderAt, size int64, m MapReader) (Type, bool) {
+	for typ, matcher := range m {
+		if matcher(r, size) {
+			return typ, true
+		}
+	}
+
+	return Type{}, false
+}
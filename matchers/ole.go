@@ -0,0 +1,314 @@
+package matchers
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Compound File Binary (a.k.a. OLE2) structural constants.
+// https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-cfb/
+const (
+	cfbHeaderSize   = 512
+	cfbDirEntrySize = 128
+
+	cfbFreeSect   = 0xFFFFFFFF
+	cfbEndOfChain = 0xFFFFFFFE
+
+	// cfbNoStream marks an absent sibling/child link in a directory entry's
+	// red-black tree pointers.
+	cfbNoStream = 0xFFFFFFFF
+
+	// cfbMaxSectors bounds how many sectors we'll follow down a single FAT
+	// chain, so a malformed or cyclic chain can't spin forever.
+	cfbMaxSectors = 1 << 16
+
+	// cfbMaxDIFATSectors bounds how many DIFAT sectors we'll follow, the
+	// same way cfbMaxSectors bounds a FAT chain: the field driving the loop
+	// comes straight from the file and a cycle back to an already-visited,
+	// individually-valid sector would otherwise spin forever.
+	cfbMaxDIFATSectors = 1 << 16
+
+	// cfbRootStorage is the directory entry object type for the root
+	// storage entry, which MS-CFB guarantees is always present and always
+	// first in the directory stream.
+	cfbRootStorage = 0x05
+)
+
+// msiRootCLSID is the root storage CLSID Windows Installer stamps on every
+// .msi package: {000C1084-0000-0000-C000-000000000046}, encoded the way a
+// GUID is packed into a CFB header (first three fields little-endian, the
+// rest as a plain byte sequence).
+var msiRootCLSID = [16]byte{
+	0x84, 0x10, 0x0C, 0x00,
+	0x00, 0x00,
+	0x00, 0x00,
+	0xC0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46,
+}
+
+// cfbDirEntry is a single CFB directory entry: a stream or storage, with
+// just the fields needed to classify the container. left/right/child are
+// stream IDs (indices into the same directory array) forming the
+// red-black tree of this entry's siblings and, for storages, its own
+// children -- a flat scan of all entries can't tell a top-level stream
+// from one nested inside an unrelated embedded object, so callers that
+// care about nesting need these to walk the tree themselves.
+type cfbDirEntry struct {
+	name               []byte // raw UTF-16LE name, without the trailing NUL
+	typ                byte
+	clsid              [16]byte
+	left, right, child uint32
+}
+
+// cfbReader gives sector- and FAT-chain-aware access to a compound file, so
+// the directory stream can be found by walking its chain instead of
+// assuming it lives in a fixed sector (true only for small, simple files).
+type cfbReader struct {
+	buf        []byte
+	sectorSize int
+	fat        []uint32
+}
+
+// parseCFB reads a CFB header and its FAT (following any DIFAT sectors
+// needed for files with more FAT sectors than fit in the header). ok is
+// false if buf doesn't start with the CFB signature or is too short to
+// contain a header.
+func parseCFB(buf []byte) (r *cfbReader, firstDirSector uint32, ok bool) {
+	if len(buf) < cfbHeaderSize {
+		return nil, 0, false
+	}
+	if !compareBytes(buf, []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}, 0) {
+		return nil, 0, false
+	}
+
+	sectorShift := binary.LittleEndian.Uint16(buf[30:32])
+	if sectorShift < 7 || sectorShift > 16 {
+		return nil, 0, false
+	}
+
+	r = &cfbReader{buf: buf, sectorSize: 1 << sectorShift}
+	firstDirSector = binary.LittleEndian.Uint32(buf[48:52])
+	numFATSectors := int(binary.LittleEndian.Uint32(buf[44:48]))
+	firstDIFATSector := binary.LittleEndian.Uint32(buf[68:72])
+	numDIFATSectors := int(binary.LittleEndian.Uint32(buf[72:76]))
+
+	// The first 109 FAT sector locations live in the header itself.
+	var difat []uint32
+	for i := 0; i < 109; i++ {
+		off := 76 + i*4
+		difat = append(difat, binary.LittleEndian.Uint32(buf[off:off+4]))
+	}
+
+	entriesPerDIFATSector := r.sectorSize/4 - 1
+	visited := make(map[uint32]bool)
+	sec := firstDIFATSector
+	for i := 0; i < numDIFATSectors && i < cfbMaxDIFATSectors && sec != cfbEndOfChain && sec != cfbFreeSect && !visited[sec]; i++ {
+		visited[sec] = true
+
+		data, sok := r.sector(sec)
+		if !sok {
+			break
+		}
+		for j := 0; j < entriesPerDIFATSector; j++ {
+			off := j * 4
+			difat = append(difat, binary.LittleEndian.Uint32(data[off:off+4]))
+		}
+		sec = binary.LittleEndian.Uint32(data[entriesPerDIFATSector*4:])
+	}
+
+	var fat []uint32
+	for i := 0; i < numFATSectors && i < len(difat); i++ {
+		data, sok := r.sector(difat[i])
+		if !sok {
+			break
+		}
+		for off := 0; off+4 <= len(data); off += 4 {
+			fat = append(fat, binary.LittleEndian.Uint32(data[off:off+4]))
+		}
+	}
+	r.fat = fat
+
+	return r, firstDirSector, true
+}
+
+// sector returns the raw bytes of sector n. Per the CFB spec, sector 0
+// always starts 512 bytes into the file regardless of sector size.
+func (r *cfbReader) sector(n uint32) ([]byte, bool) {
+	if n == cfbFreeSect || n == cfbEndOfChain {
+		return nil, false
+	}
+
+	start := cfbHeaderSize + int64(n)*int64(r.sectorSize)
+	end := start + int64(r.sectorSize)
+	if start < 0 || end > int64(len(r.buf)) {
+		return nil, false
+	}
+
+	return r.buf[start:end], true
+}
+
+// chain follows the FAT starting at sector first and returns the
+// concatenated contents of every sector in that chain. visited guards
+// against a cycle in the chain (e.g. FAT[2]=3, FAT[3]=2): without it,
+// cfbMaxSectors is just a ceiling a cycle always reaches, re-reading and
+// appending the same sectors up to 65536 times.
+func (r *cfbReader) chain(first uint32) []byte {
+	var out []byte
+
+	visited := make(map[uint32]bool)
+	sec := first
+	for i := 0; i < cfbMaxSectors && sec != cfbEndOfChain && sec != cfbFreeSect && !visited[sec]; i++ {
+		visited[sec] = true
+
+		data, ok := r.sector(sec)
+		if !ok {
+			break
+		}
+		out = append(out, data...)
+
+		if int(sec) >= len(r.fat) {
+			break
+		}
+		sec = r.fat[sec]
+	}
+
+	return out
+}
+
+// cfbUnallocated is the directory entry object type marking an unused
+// slot in the directory array.
+const cfbUnallocated = 0
+
+// directoryEntries walks the directory stream (chained from
+// firstDirSector) and returns every entry, allocated or not, indexed
+// exactly as the underlying directory array is: left/right/child stream
+// IDs on other entries are positions into this same slice, so skipping
+// entries here would make those pointers point at the wrong node.
+func (r *cfbReader) directoryEntries(firstDirSector uint32) []cfbDirEntry {
+	data := r.chain(firstDirSector)
+
+	var entries []cfbDirEntry
+	for off := 0; off+cfbDirEntrySize <= len(data); off += cfbDirEntrySize {
+		e := data[off : off+cfbDirEntrySize]
+
+		entry := cfbDirEntry{
+			typ:   e[66],
+			left:  binary.LittleEndian.Uint32(e[68:72]),
+			right: binary.LittleEndian.Uint32(e[72:76]),
+			child: binary.LittleEndian.Uint32(e[76:80]),
+		}
+		copy(entry.clsid[:], e[80:96])
+
+		if nameLen := int(binary.LittleEndian.Uint16(e[64:66])); nameLen >= 2 && nameLen <= 64 {
+			entry.name = e[0 : nameLen-2] // drop the trailing UTF-16 NUL
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// cfbDirectChildren returns the entries directly owned by the storage
+// whose child stream ID is root (e.g. the root storage's own child
+// pointer), by walking the red-black tree of siblings rooted there. It
+// does not descend into any child's own child pointer, since that tree
+// belongs to a nested storage and its entries aren't direct children of
+// root. depth is capped at len(entries) -- the most nodes a well-formed
+// tree over this entry set could have -- so a cyclic left/right pointer
+// can't recurse forever.
+func cfbDirectChildren(entries []cfbDirEntry, root uint32) []cfbDirEntry {
+	var out []cfbDirEntry
+	var walk func(id uint32, depth int)
+	walk = func(id uint32, depth int) {
+		if id == cfbNoStream || depth > len(entries) || int(id) >= len(entries) {
+			return
+		}
+
+		e := entries[id]
+		walk(e.left, depth+1)
+		out = append(out, e)
+		walk(e.right, depth+1)
+	}
+	walk(root, 0)
+
+	return out
+}
+
+// utf16leASCIIName decodes a raw UTF-16LE directory entry name, returning
+// nil if it contains any character outside ASCII. CFB directory names are
+// usually plain ASCII, but non-ASCII ones (e.g. streams named in another
+// script) are unremarkable and not a classification signal by themselves.
+func utf16leASCIIName(raw []byte) []byte {
+	out := make([]byte, 0, len(raw)/2)
+	for i := 0; i+1 < len(raw); i += 2 {
+		if raw[i+1] != 0 {
+			return nil
+		}
+		out = append(out, raw[i])
+	}
+	return out
+}
+
+// oleType classifies a CFB/OLE2 compound file by walking its directory
+// stream, rather than peeking at a fixed byte offset that assumes the root
+// directory lives in sector 1. Most formats are identified by a stream
+// name directly under the root storage; Windows Installer packages are
+// identified by the root storage entry's CLSID instead, since MSI's own
+// naming scheme for nested streams/storages produces names that are
+// otherwise indistinguishable from ordinary non-ASCII ones.
+//
+// Only direct children of the root storage are considered for the
+// name-based checks: a WordDocument containing an embedded Excel chart,
+// or a PowerPoint slide with an embedded workbook, both have a
+// "Workbook"/"WordDocument" stream nested inside the embedded object's
+// own storage, and that must not be mistaken for the container's own
+// type just because a flat scan happened to see it.
+func oleType(buf []byte) (typ docType, found bool) {
+	r, firstDirSector, ok := parseCFB(buf)
+	if !ok {
+		return
+	}
+
+	entries := r.directoryEntries(firstDirSector)
+	if len(entries) == 0 || entries[0].typ != cfbRootStorage {
+		return
+	}
+
+	root := entries[0]
+	if root.clsid == msiRootCLSID {
+		return TYPE_MSI, true
+	}
+
+	hasOutlookProps := false
+	for _, e := range cfbDirectChildren(entries, root.child) {
+		if e.typ == cfbUnallocated {
+			continue
+		}
+
+		name := utf16leASCIIName(e.name)
+		if name == nil {
+			continue
+		}
+
+		switch string(name) {
+		case "WordDocument":
+			return TYPE_DOC, true
+		case "Workbook", "Book":
+			return TYPE_XLS, true
+		case "PowerPoint Document":
+			return TYPE_PPT, true
+		case "Quill":
+			return TYPE_PUB, true
+		}
+
+		if bytes.HasPrefix(name, []byte("__substg1.0_")) {
+			hasOutlookProps = true
+		}
+	}
+
+	if hasOutlookProps {
+		return TYPE_MSG, true
+	}
+
+	return
+}
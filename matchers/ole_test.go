@@ -0,0 +1,234 @@
+package matchers
+
+import (
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+)
+
+// buildCFBEntry encodes a single 128-byte CFB directory entry for name,
+// with the given object type (1 = storage, 2 = stream, 5 = root storage),
+// CLSID, and red-black tree pointers (use cfbNoStream for "no link").
+func buildCFBEntry(name string, typ byte, clsid [16]byte, left, right, child uint32) []byte {
+	e := make([]byte, cfbDirEntrySize)
+
+	units := utf16.Encode([]rune(name))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(e[i*2:], u)
+	}
+	nameLenBytes := uint16(len(units)*2 + 2) // include the trailing NUL
+	binary.LittleEndian.PutUint16(e[64:66], nameLenBytes)
+	e[66] = typ
+	binary.LittleEndian.PutUint32(e[68:72], left)
+	binary.LittleEndian.PutUint32(e[72:76], right)
+	binary.LittleEndian.PutUint32(e[76:80], child)
+	copy(e[80:96], clsid[:])
+
+	return e
+}
+
+// buildCFB assembles a minimal compound file with 512-byte sectors: a
+// single FAT sector followed by one or more directory sectors, each filled
+// with up to 4 directory entries (128 bytes * 4 = one 512-byte sector).
+// Unused entry slots are left zeroed (unallocated).
+func buildCFB(dirSectors [][]byte) []byte {
+	const sectorSize = 512
+
+	header := make([]byte, cfbHeaderSize)
+	copy(header[0:8], []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1})
+	binary.LittleEndian.PutUint16(header[30:32], 9) // sector shift -> 512-byte sectors
+	binary.LittleEndian.PutUint32(header[44:48], 1) // one FAT sector
+	binary.LittleEndian.PutUint32(header[48:52], 1) // first directory sector
+	binary.LittleEndian.PutUint32(header[68:72], cfbEndOfChain)
+	// difat[0] = 0: the FAT lives in the first sector after the header.
+	binary.LittleEndian.PutUint32(header[76:80], 0)
+	for i := 1; i < 109; i++ {
+		binary.LittleEndian.PutUint32(header[76+i*4:76+i*4+4], cfbFreeSect)
+	}
+
+	fat := make([]byte, sectorSize)
+	for i := range fat {
+		fat[i] = 0xFF // default every entry to FREESECT
+	}
+	for i := range dirSectors {
+		next := uint32(cfbEndOfChain)
+		if i < len(dirSectors)-1 {
+			next = uint32(2 + i)
+		}
+		binary.LittleEndian.PutUint32(fat[(1+i)*4:], next)
+	}
+
+	buf := append([]byte{}, header...)
+	buf = append(buf, fat...)
+	for _, sec := range dirSectors {
+		padded := make([]byte, sectorSize)
+		copy(padded, sec)
+		buf = append(buf, padded...)
+	}
+
+	return buf
+}
+
+func buildDirSector(entries ...[]byte) []byte {
+	sec := make([]byte, 0, 512)
+	for _, e := range entries {
+		sec = append(sec, e...)
+	}
+	return sec
+}
+
+var zeroCLSID [16]byte
+
+func TestOleTypeWordDocument(t *testing.T) {
+	buf := buildCFB([][]byte{
+		buildDirSector(
+			buildCFBEntry("Root Entry", cfbRootStorage, zeroCLSID, cfbNoStream, cfbNoStream, 1),
+			buildCFBEntry("WordDocument", 2, zeroCLSID, cfbNoStream, cfbNoStream, cfbNoStream),
+		),
+	})
+
+	typ, ok := oleType(buf)
+	if !ok || typ != TYPE_DOC {
+		t.Fatalf("got (%v, %v), want (TYPE_DOC, true)", typ, ok)
+	}
+}
+
+func TestOleTypeMSIByCLSID(t *testing.T) {
+	buf := buildCFB([][]byte{
+		buildDirSector(
+			buildCFBEntry("Root Entry", cfbRootStorage, msiRootCLSID, cfbNoStream, cfbNoStream, cfbNoStream),
+		),
+	})
+
+	typ, ok := oleType(buf)
+	if !ok || typ != TYPE_MSI {
+		t.Fatalf("got (%v, %v), want (TYPE_MSI, true)", typ, ok)
+	}
+}
+
+func TestOleTypeNonASCIINameIsNotMSI(t *testing.T) {
+	// A Cyrillic stream name has a non-zero high byte in its UTF-16LE
+	// encoding, same as an MSI-mangled name would -- this must not be
+	// enough on its own to classify the file as MSI.
+	buf := buildCFB([][]byte{
+		buildDirSector(
+			buildCFBEntry("Root Entry", cfbRootStorage, zeroCLSID, cfbNoStream, cfbNoStream, 1),
+			buildCFBEntry("Фото", 2, zeroCLSID, cfbNoStream, cfbNoStream, cfbNoStream),
+		),
+	})
+
+	if typ, ok := oleType(buf); ok {
+		t.Fatalf("got (%v, true), want not found", typ)
+	}
+}
+
+func TestOleTypeMultiSectorDirectoryChain(t *testing.T) {
+	// Spread the directory stream over two sectors so the target entry
+	// (stream ID 4, the first slot of the second sector) only turns up
+	// after following both the FAT chain and the child pointer.
+	buf := buildCFB([][]byte{
+		buildDirSector(
+			buildCFBEntry("Root Entry", cfbRootStorage, zeroCLSID, cfbNoStream, cfbNoStream, 4),
+		),
+		buildDirSector(
+			buildCFBEntry("WordDocument", 2, zeroCLSID, cfbNoStream, cfbNoStream, cfbNoStream),
+		),
+	})
+
+	typ, ok := oleType(buf)
+	if !ok || typ != TYPE_DOC {
+		t.Fatalf("got (%v, %v), want (TYPE_DOC, true)", typ, ok)
+	}
+}
+
+func TestOleTypeXlsWorkbook(t *testing.T) {
+	buf := buildCFB([][]byte{
+		buildDirSector(
+			buildCFBEntry("Root Entry", cfbRootStorage, zeroCLSID, cfbNoStream, cfbNoStream, 1),
+			buildCFBEntry("Workbook", 2, zeroCLSID, cfbNoStream, cfbNoStream, cfbNoStream),
+		),
+	})
+
+	typ, ok := oleType(buf)
+	if !ok || typ != TYPE_XLS {
+		t.Fatalf("got (%v, %v), want (TYPE_XLS, true)", typ, ok)
+	}
+}
+
+func TestOleTypeXlsBook(t *testing.T) {
+	// Older Excel versions name the stream "Book" instead of "Workbook".
+	buf := buildCFB([][]byte{
+		buildDirSector(
+			buildCFBEntry("Root Entry", cfbRootStorage, zeroCLSID, cfbNoStream, cfbNoStream, 1),
+			buildCFBEntry("Book", 2, zeroCLSID, cfbNoStream, cfbNoStream, cfbNoStream),
+		),
+	})
+
+	typ, ok := oleType(buf)
+	if !ok || typ != TYPE_XLS {
+		t.Fatalf("got (%v, %v), want (TYPE_XLS, true)", typ, ok)
+	}
+}
+
+func TestOleTypePptPowerPointDocument(t *testing.T) {
+	buf := buildCFB([][]byte{
+		buildDirSector(
+			buildCFBEntry("Root Entry", cfbRootStorage, zeroCLSID, cfbNoStream, cfbNoStream, 1),
+			buildCFBEntry("PowerPoint Document", 2, zeroCLSID, cfbNoStream, cfbNoStream, cfbNoStream),
+		),
+	})
+
+	typ, ok := oleType(buf)
+	if !ok || typ != TYPE_PPT {
+		t.Fatalf("got (%v, %v), want (TYPE_PPT, true)", typ, ok)
+	}
+}
+
+func TestOleTypeMsgSubstgPrefix(t *testing.T) {
+	// Outlook .msg files have no single named stream to key off of; every
+	// MAPI property is its own "__substg1.0_XXXXYYYY" stream instead.
+	buf := buildCFB([][]byte{
+		buildDirSector(
+			buildCFBEntry("Root Entry", cfbRootStorage, zeroCLSID, cfbNoStream, cfbNoStream, 1),
+			buildCFBEntry("__substg1.0_0037001F", 2, zeroCLSID, cfbNoStream, cfbNoStream, cfbNoStream),
+		),
+	})
+
+	typ, ok := oleType(buf)
+	if !ok || typ != TYPE_MSG {
+		t.Fatalf("got (%v, %v), want (TYPE_MSG, true)", typ, ok)
+	}
+}
+
+func TestOleTypePubQuill(t *testing.T) {
+	buf := buildCFB([][]byte{
+		buildDirSector(
+			buildCFBEntry("Root Entry", cfbRootStorage, zeroCLSID, cfbNoStream, cfbNoStream, 1),
+			buildCFBEntry("Quill", 2, zeroCLSID, cfbNoStream, cfbNoStream, cfbNoStream),
+		),
+	})
+
+	typ, ok := oleType(buf)
+	if !ok || typ != TYPE_PUB {
+		t.Fatalf("got (%v, %v), want (TYPE_PUB, true)", typ, ok)
+	}
+}
+
+func TestOleTypeIgnoresNestedEmbeddedObjectStreams(t *testing.T) {
+	// A WordDocument with an embedded Excel chart: the embedded object's
+	// own "Workbook" stream lives inside its own storage, two levels down
+	// from the root, and must not be mistaken for the container's type.
+	buf := buildCFB([][]byte{
+		buildDirSector(
+			buildCFBEntry("Root Entry", cfbRootStorage, zeroCLSID, cfbNoStream, cfbNoStream, 1),
+			buildCFBEntry("WordDocument", 2, zeroCLSID, cfbNoStream, 2, cfbNoStream),
+			buildCFBEntry("Embedded Object", 1, zeroCLSID, cfbNoStream, cfbNoStream, 3),
+			buildCFBEntry("Workbook", 2, zeroCLSID, cfbNoStream, cfbNoStream, cfbNoStream),
+		),
+	})
+
+	typ, ok := oleType(buf)
+	if !ok || typ != TYPE_DOC {
+		t.Fatalf("got (%v, %v), want (TYPE_DOC, true) -- nested Workbook stream must not win", typ, ok)
+	}
+}
@@ -0,0 +1,39 @@
+package matchers
+
+import "io"
+
+// ReaderMatcher is the streaming counterpart of the plain []byte matcher
+// functions in this package: it receives random read access to the whole
+// file plus its size instead of a pre-loaded buffer. Formats that only need
+// to inspect the end of a file (notably the ZIP-based OOXML/ODF matchers)
+// can use this to avoid forcing the caller to read a large file in full
+// just to sniff it.
+type ReaderMatcher func(r io.ReaderAt, size int64) bool
+
+// MapReader mirrors Map, but keyed to ReaderMatcher instead of the plain
+// []byte matcher signature.
+type MapReader map[Type]ReaderMatcher
+
+// sizedReader adapts a []byte to io.ReaderAt, so the existing buffer-based
+// matchers can be driven through the same central-directory lookup code as
+// their ReaderAt counterparts.
+type sizedReader struct {
+	buf []byte
+}
+
+func newSizedReader(buf []byte) sizedReader {
+	return sizedReader{buf: buf}
+}
+
+func (r sizedReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 || off >= int64(len(r.buf)) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, r.buf[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
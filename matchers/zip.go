@@ -0,0 +1,265 @@
+package matchers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// ZIP record signatures, as defined by the ZIP file format spec.
+const (
+	zipLocalFileHeaderSig = 0x04034b50
+	zipCentralDirSig      = 0x02014b50
+	zipEndOfCentralDirSig = 0x06054b50
+
+	// ZIP64 variants of the end-of-central-directory record: used when the
+	// standard (32-bit) record's central directory size/offset fields
+	// overflow and are set to the zip64Placeholder value instead, meaning
+	// "see the locator and record below for the real values".
+	zip64EndOfCentralDirSig     = 0x06064b50
+	zip64EndOfCentralDirLocSig  = 0x07064b50
+	zip64EndOfCentralDirLocSize = 20
+	zip64Placeholder            = 0xFFFFFFFF
+
+	// maxEOCDSearch bounds how far back from the end of the archive we look
+	// for the end-of-central-directory record: 22 fixed bytes plus the
+	// largest possible archive comment.
+	maxEOCDSearch = 22 + 65535
+)
+
+// zipEntry is the subset of a central directory file header we care about
+// for sniffing: its name, where its local file header (and therefore its
+// data) lives, and its compressed size — the central directory's copy of
+// that size is authoritative even when the local header's own copy is
+// zeroed out by the data-descriptor flag.
+type zipEntry struct {
+	name              []byte
+	localHeaderOffset uint32
+	compressedSize    uint32
+}
+
+// zipContains reports whether any of names is present as an entry name in
+// the ZIP (or ZIP-based, e.g. OOXML/ODF) archive in buf. When the full
+// end-of-central-directory record is available it reads the central
+// directory directly, which is O(1) I/O regardless of archive size and
+// unaffected by large extra fields or reordered entries. It follows the
+// ZIP64 locator/record when the standard EOCD's central directory
+// size/offset overflow. It doesn't parse a ZIP64 extra field on an
+// individual entry; name lookup doesn't need it, but zipReadFile can't read
+// an entry whose own size overflows 32 bits. If buf is a truncated prefix of
+// a larger archive (no EOCD in
+// range), it falls back to scanning local file headers from the start of
+// buf.
+func zipContains(buf []byte, names ...[]byte) bool {
+	if entries, ok := zipCentralDirEntries(buf); ok {
+		for _, e := range entries {
+			for _, name := range names {
+				if bytes.Equal(e.name, name) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	for _, entryName := range zipLocalHeaderNames(buf) {
+		for _, name := range names {
+			if bytes.Equal(entryName, name) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// zipContainsReader is the io.ReaderAt counterpart of zipContains. It reads
+// only the end-of-central-directory record and the central directory
+// itself, so the caller never has to read a large archive in full just to
+// sniff it. Unlike zipContains it has no local-header fallback: size is
+// assumed to be the true length of the underlying file, not a truncated
+// sniffing buffer.
+func zipContainsReader(r io.ReaderAt, size int64, names ...[]byte) bool {
+	entries, ok := zipCentralDirEntriesReader(r, size)
+	if !ok {
+		return false
+	}
+
+	for _, e := range entries {
+		for _, name := range names {
+			if bytes.Equal(e.name, name) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// zipCentralDirEntries locates and parses the central directory of the ZIP
+// archive in buf. ok is false when the end-of-central-directory record (and
+// therefore the central directory itself) isn't fully contained in buf. It's
+// built on top of the io.ReaderAt path via sizedReader, so the lookup logic
+// only needs to exist once.
+func zipCentralDirEntries(buf []byte) (entries []zipEntry, ok bool) {
+	return zipCentralDirEntriesReader(newSizedReader(buf), int64(len(buf)))
+}
+
+// zipCentralDirEntriesReader reads just the tail of the file to find the
+// end-of-central-directory record, then reads and parses the central
+// directory it points to.
+func zipCentralDirEntriesReader(r io.ReaderAt, size int64) (entries []zipEntry, ok bool) {
+	tailLen := int64(maxEOCDSearch)
+	if tailLen > size {
+		tailLen = size
+	}
+
+	tail := make([]byte, tailLen)
+	if _, err := r.ReadAt(tail, size-tailLen); err != nil && err != io.EOF {
+		return nil, false
+	}
+
+	eocd, found := findEOCD(tail)
+	if !found {
+		return nil, false
+	}
+
+	cdSize := int64(binary.LittleEndian.Uint32(tail[eocd+12:]))
+	cdOffset := int64(binary.LittleEndian.Uint32(tail[eocd+16:]))
+	if cdSize == zip64Placeholder || cdOffset == zip64Placeholder {
+		var zok bool
+		cdSize, cdOffset, zok = zip64CentralDirLocation(r, size, tail, eocd)
+		if !zok {
+			return nil, false
+		}
+	}
+	if cdOffset < 0 || cdSize < 0 || cdOffset+cdSize > size {
+		return nil, false
+	}
+
+	cd := make([]byte, cdSize)
+	if _, err := r.ReadAt(cd, cdOffset); err != nil && err != io.EOF {
+		return nil, false
+	}
+
+	return parseCentralDir(cd), true
+}
+
+// zip64CentralDirLocation reads the ZIP64 end-of-central-directory locator,
+// which immediately precedes the standard EOCD record at offset eocd within
+// tail, and follows it to the ZIP64 EOCD record itself to recover the
+// central directory's true size and offset. This only covers archives
+// where the central directory as a whole overflows 32 bits (very large
+// archives, or ones with more than 65535 entries); an individual entry
+// whose own compressed/uncompressed size overflows 32 bits needs its
+// ZIP64 extra field parsed too, which parseCentralDir doesn't do.
+func zip64CentralDirLocation(r io.ReaderAt, size int64, tail []byte, eocd int) (cdSize, cdOffset int64, ok bool) {
+	locOffset := eocd - zip64EndOfCentralDirLocSize
+	if locOffset < 0 || binary.LittleEndian.Uint32(tail[locOffset:]) != zip64EndOfCentralDirLocSig {
+		return 0, 0, false
+	}
+
+	recordOffset := int64(binary.LittleEndian.Uint64(tail[locOffset+8:]))
+	if recordOffset < 0 || recordOffset+56 > size {
+		return 0, 0, false
+	}
+
+	record := make([]byte, 56)
+	if _, err := r.ReadAt(record, recordOffset); err != nil && err != io.EOF {
+		return 0, 0, false
+	}
+	if binary.LittleEndian.Uint32(record) != zip64EndOfCentralDirSig {
+		return 0, 0, false
+	}
+
+	cdSize = int64(binary.LittleEndian.Uint64(record[40:]))
+	cdOffset = int64(binary.LittleEndian.Uint64(record[48:]))
+	return cdSize, cdOffset, true
+}
+
+// parseCentralDir walks a buffer holding exactly the central directory
+// (as found via the end-of-central-directory record) and returns its
+// entries.
+func parseCentralDir(cd []byte) (entries []zipEntry) {
+	pos := 0
+	for pos+46 <= len(cd) {
+		if binary.LittleEndian.Uint32(cd[pos:]) != zipCentralDirSig {
+			break
+		}
+
+		compressedSize := binary.LittleEndian.Uint32(cd[pos+20:])
+		nameLen := int(binary.LittleEndian.Uint16(cd[pos+28:]))
+		extraLen := int(binary.LittleEndian.Uint16(cd[pos+30:]))
+		commentLen := int(binary.LittleEndian.Uint16(cd[pos+32:]))
+		localOffset := binary.LittleEndian.Uint32(cd[pos+42:])
+
+		nameStart := pos + 46
+		nameEnd := nameStart + nameLen
+		if nameEnd > len(cd) {
+			break
+		}
+
+		entries = append(entries, zipEntry{
+			name:              cd[nameStart:nameEnd],
+			localHeaderOffset: localOffset,
+			compressedSize:    compressedSize,
+		})
+
+		pos = nameEnd + extraLen + commentLen
+	}
+
+	return entries
+}
+
+// findEOCD searches the tail of buf for the end-of-central-directory
+// signature, returning its offset.
+func findEOCD(buf []byte) (int, bool) {
+	searchStart := len(buf) - maxEOCDSearch
+	if searchStart < 0 {
+		searchStart = 0
+	}
+
+	sig := make([]byte, 4)
+	binary.LittleEndian.PutUint32(sig, zipEndOfCentralDirSig)
+	tail := buf[searchStart:]
+	idx := bytes.LastIndex(tail, sig)
+	if idx == -1 || searchStart+idx+22 > len(buf) {
+		return 0, false
+	}
+
+	return searchStart + idx, true
+}
+
+// zipLocalHeaderNames scans buf from the start for local file header
+// signatures and returns the entry name found at each one. It's a best
+// effort fallback for when buf is only a prefix of the full archive (so the
+// central directory can't be read), and doesn't attempt to skip over file
+// data between headers.
+func zipLocalHeaderNames(buf []byte) [][]byte {
+	var names [][]byte
+	sig := make([]byte, 4)
+	binary.LittleEndian.PutUint32(sig, zipLocalFileHeaderSig)
+
+	pos := 0
+	for {
+		idx := bytes.Index(buf[pos:], sig)
+		if idx == -1 {
+			return names
+		}
+
+		start := pos + idx
+		if start+30 > len(buf) {
+			return names
+		}
+
+		nameLen := int(binary.LittleEndian.Uint16(buf[start+26:]))
+		nameStart := start + 30
+		nameEnd := nameStart + nameLen
+		if nameEnd > len(buf) {
+			return names
+		}
+
+		names = append(names, buf[nameStart:nameEnd])
+		pos = nameStart + 1
+	}
+}
@@ -0,0 +1,276 @@
+package matchers
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+)
+
+type testZipEntry struct {
+	name           string
+	data           []byte
+	method         uint16 // 0 stored, 8 deflate
+	dataDescriptor bool
+	extraField     []byte // written into the local file header only, as real writers do
+}
+
+// buildZip assembles a minimal, valid ZIP archive (local headers + central
+// directory + EOCD) from entries, optionally marking some of them with the
+// data-descriptor flag the way streaming writers (e.g. archive/zip) do.
+func buildZip(t *testing.T, entries []testZipEntry) []byte {
+	t.Helper()
+
+	type centralRecord struct {
+		name             []byte
+		compressedSize   uint32
+		uncompressedSize uint32
+		crc              uint32
+		method           uint16
+		flags            uint16
+		localOffset      uint32
+	}
+
+	var buf bytes.Buffer
+	var central []centralRecord
+
+	for _, e := range entries {
+		localOffset := uint32(buf.Len())
+
+		var compressed []byte
+		switch e.method {
+		case 0:
+			compressed = e.data
+		case 8:
+			var cbuf bytes.Buffer
+			fw, err := flate.NewWriter(&cbuf, flate.DefaultCompression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := fw.Write(e.data); err != nil {
+				t.Fatal(err)
+			}
+			if err := fw.Close(); err != nil {
+				t.Fatal(err)
+			}
+			compressed = cbuf.Bytes()
+		default:
+			t.Fatalf("unsupported method %d", e.method)
+		}
+
+		crc := crc32.ChecksumIEEE(e.data)
+
+		var flags uint16
+		if e.dataDescriptor {
+			flags |= zipDataDescriptor
+		}
+
+		header := make([]byte, 30)
+		binary.LittleEndian.PutUint32(header[0:], zipLocalFileHeaderSig)
+		binary.LittleEndian.PutUint16(header[6:], flags)
+		binary.LittleEndian.PutUint16(header[8:], e.method)
+		if !e.dataDescriptor {
+			binary.LittleEndian.PutUint32(header[14:], crc)
+			binary.LittleEndian.PutUint32(header[18:], uint32(len(compressed)))
+			binary.LittleEndian.PutUint32(header[22:], uint32(len(e.data)))
+		}
+		binary.LittleEndian.PutUint16(header[26:], uint16(len(e.name)))
+		binary.LittleEndian.PutUint16(header[28:], uint16(len(e.extraField)))
+
+		buf.Write(header)
+		buf.WriteString(e.name)
+		buf.Write(e.extraField)
+		buf.Write(compressed)
+
+		if e.dataDescriptor {
+			dd := make([]byte, 12)
+			binary.LittleEndian.PutUint32(dd[0:], crc)
+			binary.LittleEndian.PutUint32(dd[4:], uint32(len(compressed)))
+			binary.LittleEndian.PutUint32(dd[8:], uint32(len(e.data)))
+			buf.Write(dd)
+		}
+
+		central = append(central, centralRecord{
+			name:             []byte(e.name),
+			compressedSize:   uint32(len(compressed)),
+			uncompressedSize: uint32(len(e.data)),
+			crc:              crc,
+			method:           e.method,
+			flags:            flags,
+			localOffset:      localOffset,
+		})
+	}
+
+	cdStart := uint32(buf.Len())
+	for _, c := range central {
+		rec := make([]byte, 46)
+		binary.LittleEndian.PutUint32(rec[0:], zipCentralDirSig)
+		binary.LittleEndian.PutUint16(rec[8:], c.flags)
+		binary.LittleEndian.PutUint16(rec[10:], c.method)
+		binary.LittleEndian.PutUint32(rec[16:], c.crc)
+		binary.LittleEndian.PutUint32(rec[20:], c.compressedSize)
+		binary.LittleEndian.PutUint32(rec[24:], c.uncompressedSize)
+		binary.LittleEndian.PutUint16(rec[28:], uint16(len(c.name)))
+		binary.LittleEndian.PutUint32(rec[42:], c.localOffset)
+
+		buf.Write(rec)
+		buf.Write(c.name)
+	}
+	cdSize := uint32(buf.Len()) - cdStart
+
+	eocd := make([]byte, 22)
+	binary.LittleEndian.PutUint32(eocd[0:], zipEndOfCentralDirSig)
+	binary.LittleEndian.PutUint16(eocd[8:], uint16(len(central)))
+	binary.LittleEndian.PutUint16(eocd[10:], uint16(len(central)))
+	binary.LittleEndian.PutUint32(eocd[12:], cdSize)
+	binary.LittleEndian.PutUint32(eocd[16:], cdStart)
+	buf.Write(eocd)
+
+	return buf.Bytes()
+}
+
+func TestZipContains(t *testing.T) {
+	archive := buildZip(t, []testZipEntry{
+		{name: "word/document.xml", data: []byte("<xml/>"), method: 8},
+	})
+
+	if !zipContains(archive, []byte("word/document.xml")) {
+		t.Fatal("expected entry to be found via the central directory")
+	}
+	if zipContains(archive, []byte("missing")) {
+		t.Fatal("unexpected match for an absent entry")
+	}
+}
+
+func TestZipContainsTruncatedBuffer(t *testing.T) {
+	archive := buildZip(t, []testZipEntry{
+		{name: "word/document.xml", data: []byte("<xml/>"), method: 0},
+	})
+
+	// Keep only the local header and data, the shape a size-bounded sniff
+	// buffer produces: no central directory or EOCD in range.
+	truncated := archive[:30+len("word/document.xml")+len("<xml/>")]
+
+	if !zipContains(truncated, []byte("word/document.xml")) {
+		t.Fatal("expected local-header scan to find the entry in a truncated buffer")
+	}
+}
+
+func TestZipReadFileDataDescriptor(t *testing.T) {
+	want := []byte(`<Types><Override ContentType="...wordprocessingml.template..."/></Types>`)
+	archive := buildZip(t, []testZipEntry{
+		{name: "[Content_Types].xml", data: want, method: 8, dataDescriptor: true},
+	})
+
+	got, ok := zipReadFile(archive, []byte("[Content_Types].xml"))
+	if !ok {
+		t.Fatal("expected to read an entry written with the data-descriptor flag set")
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestZipReadFileTruncatedFallback(t *testing.T) {
+	data := []byte("plain contents")
+	archive := buildZip(t, []testZipEntry{
+		{name: "mimetype", data: data, method: 0},
+	})
+
+	truncated := archive[:30+len("mimetype")+len(data)]
+
+	got, ok := zipReadFile(truncated, []byte("mimetype"))
+	if !ok {
+		t.Fatal("expected the scan fallback to read the entry from a truncated buffer")
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("got %q, want %q", got, data)
+	}
+}
+
+// buildZip64 assembles a single-entry, stored-method ZIP archive whose
+// standard end-of-central-directory record carries the ZIP64 placeholder
+// for its central directory size/offset, requiring the reader to follow
+// the ZIP64 locator and record instead.
+func buildZip64(t *testing.T, name string, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	localOffset := uint32(buf.Len())
+	crc := crc32.ChecksumIEEE(data)
+
+	header := make([]byte, 30)
+	binary.LittleEndian.PutUint32(header[0:], zipLocalFileHeaderSig)
+	binary.LittleEndian.PutUint32(header[14:], crc)
+	binary.LittleEndian.PutUint32(header[18:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(header[22:], uint32(len(data)))
+	binary.LittleEndian.PutUint16(header[26:], uint16(len(name)))
+	buf.Write(header)
+	buf.WriteString(name)
+	buf.Write(data)
+
+	cdStart := uint32(buf.Len())
+	rec := make([]byte, 46)
+	binary.LittleEndian.PutUint32(rec[0:], zipCentralDirSig)
+	binary.LittleEndian.PutUint32(rec[16:], crc)
+	binary.LittleEndian.PutUint32(rec[20:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(rec[24:], uint32(len(data)))
+	binary.LittleEndian.PutUint16(rec[28:], uint16(len(name)))
+	binary.LittleEndian.PutUint32(rec[42:], localOffset)
+	buf.Write(rec)
+	buf.WriteString(name)
+	cdSize := uint32(buf.Len()) - cdStart
+
+	zip64Record := make([]byte, 56)
+	binary.LittleEndian.PutUint32(zip64Record[0:], zip64EndOfCentralDirSig)
+	binary.LittleEndian.PutUint64(zip64Record[4:], 44) // record size after this field
+	binary.LittleEndian.PutUint64(zip64Record[24:], 1) // entries, this disk
+	binary.LittleEndian.PutUint64(zip64Record[32:], 1) // entries, total
+	binary.LittleEndian.PutUint64(zip64Record[40:], uint64(cdSize))
+	binary.LittleEndian.PutUint64(zip64Record[48:], uint64(cdStart))
+	zip64RecordOffset := uint32(buf.Len())
+	buf.Write(zip64Record)
+
+	loc := make([]byte, zip64EndOfCentralDirLocSize)
+	binary.LittleEndian.PutUint32(loc[0:], zip64EndOfCentralDirLocSig)
+	binary.LittleEndian.PutUint64(loc[8:], uint64(zip64RecordOffset))
+	binary.LittleEndian.PutUint32(loc[16:], 1) // total number of disks
+	buf.Write(loc)
+
+	eocd := make([]byte, 22)
+	binary.LittleEndian.PutUint32(eocd[0:], zipEndOfCentralDirSig)
+	binary.LittleEndian.PutUint16(eocd[8:], 1)
+	binary.LittleEndian.PutUint16(eocd[10:], 1)
+	binary.LittleEndian.PutUint32(eocd[12:], zip64Placeholder)
+	binary.LittleEndian.PutUint32(eocd[16:], zip64Placeholder)
+	buf.Write(eocd)
+
+	return buf.Bytes()
+}
+
+func TestZipContainsZip64CentralDirLocation(t *testing.T) {
+	archive := buildZip64(t, "word/document.xml", []byte("<xml/>"))
+
+	if !zipContains(archive, []byte("word/document.xml")) {
+		t.Fatal("expected to locate the central directory via the ZIP64 locator/record")
+	}
+	if zipContains(archive, []byte("missing")) {
+		t.Fatal("unexpected match for an absent entry")
+	}
+}
+
+func TestZipReadFileTruncatedDataDescriptorGivesUp(t *testing.T) {
+	archive := buildZip(t, []testZipEntry{
+		{name: "mimetype", data: []byte("plain contents"), method: 0, dataDescriptor: true},
+	})
+
+	// With no central directory in range there's no reliable way to
+	// recover the true size of a data-descriptor entry from the local
+	// header scan alone, so this must fail rather than read garbage.
+	truncated := archive[:30+len("mimetype")+len("plain contents")]
+
+	if _, ok := zipReadFile(truncated, []byte("mimetype")); ok {
+		t.Fatal("expected the scan fallback to refuse a data-descriptor entry it can't size")
+	}
+}
@@ -0,0 +1,221 @@
+package matchers
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+)
+
+// zipDataDescriptor is general-purpose bit flag 3: when set, a local file
+// header's crc32/compressed-size/uncompressed-size fields are zeroed out
+// and the real values instead follow the file's data in a data descriptor
+// record. This is how archive/zip (and many other writers) emit entries by
+// default when streaming, so it shows up often in practice.
+const zipDataDescriptor = 0x0008
+
+// zipReadFile returns the (decompressed) contents of the named entry in the
+// ZIP archive in buf, reading its local file header via the offset recorded
+// in the central directory. It understands the two compression methods
+// OOXML/ODF parts actually use: stored (0) and deflate (8). If buf is a
+// truncated prefix of a larger archive (no central directory in range), it
+// falls back to a local-header scan, same as zipContains.
+func zipReadFile(buf []byte, name []byte) ([]byte, bool) {
+	if entries, ok := zipCentralDirEntries(buf); ok {
+		for _, e := range entries {
+			if bytes.Equal(e.name, name) {
+				return readLocalFileData(buf, e.localHeaderOffset, e.compressedSize)
+			}
+		}
+		return nil, false
+	}
+
+	return zipReadFileScan(buf, name)
+}
+
+// readLocalFileData reads a single entry's data starting at its local file
+// header. cdCompressedSize is the size recorded for the same entry in the
+// central directory, used in place of the local header's own size field
+// when the data-descriptor flag means that field is zeroed.
+func readLocalFileData(buf []byte, localHeaderOffset uint32, cdCompressedSize uint32) ([]byte, bool) {
+	off := int64(localHeaderOffset)
+	if off < 0 || off+30 > int64(len(buf)) {
+		return nil, false
+	}
+
+	if binary.LittleEndian.Uint32(buf[off:]) != zipLocalFileHeaderSig {
+		return nil, false
+	}
+
+	flags := binary.LittleEndian.Uint16(buf[off+6:])
+	method := binary.LittleEndian.Uint16(buf[off+8:])
+	compSize := int64(binary.LittleEndian.Uint32(buf[off+18:]))
+	if flags&zipDataDescriptor != 0 {
+		compSize = int64(cdCompressedSize)
+	}
+	nameLen := int64(binary.LittleEndian.Uint16(buf[off+26:]))
+	extraLen := int64(binary.LittleEndian.Uint16(buf[off+28:]))
+
+	dataStart := off + 30 + nameLen + extraLen
+	dataEnd := dataStart + compSize
+	if dataEnd > int64(len(buf)) {
+		return nil, false
+	}
+
+	return decompress(method, buf[dataStart:dataEnd])
+}
+
+// zipReadFileScan is the local-header-scan fallback for zipReadFile, used
+// when buf is only a prefix of the archive and has no central directory to
+// consult. Without it, an entry's true compressed size can't be recovered
+// when the data-descriptor flag is set, so the scan stops at the first such
+// entry and reports failure.
+func zipReadFileScan(buf []byte, name []byte) ([]byte, bool) {
+	sig := make([]byte, 4)
+	binary.LittleEndian.PutUint32(sig, zipLocalFileHeaderSig)
+
+	pos := 0
+	for {
+		idx := bytes.Index(buf[pos:], sig)
+		if idx == -1 {
+			return nil, false
+		}
+
+		off := pos + idx
+		if off+30 > len(buf) {
+			return nil, false
+		}
+
+		flags := binary.LittleEndian.Uint16(buf[off+6:])
+		method := binary.LittleEndian.Uint16(buf[off+8:])
+		compSize := int(binary.LittleEndian.Uint32(buf[off+18:]))
+		nameLen := int(binary.LittleEndian.Uint16(buf[off+26:]))
+		extraLen := int(binary.LittleEndian.Uint16(buf[off+28:]))
+
+		nameStart := off + 30
+		nameEnd := nameStart + nameLen
+		if nameEnd > len(buf) {
+			return nil, false
+		}
+		entryName := buf[nameStart:nameEnd]
+		dataStart := nameEnd + extraLen
+
+		if flags&zipDataDescriptor != 0 {
+			// The true size lives in a data descriptor after the data, which
+			// we have no reliable way to locate without the central
+			// directory. Give up rather than risk reading garbage.
+			return nil, false
+		}
+
+		dataEnd := dataStart + compSize
+		if bytes.Equal(entryName, name) {
+			if dataEnd > len(buf) {
+				return nil, false
+			}
+			return decompress(method, buf[dataStart:dataEnd])
+		}
+		if dataEnd > len(buf) {
+			return nil, false
+		}
+
+		pos = dataEnd
+	}
+}
+
+// decompress inflates data if method is deflate, or returns it unchanged if
+// method is stored. Any other method (e.g. ZIP64-only encodings) isn't
+// supported and reports failure.
+func decompress(method uint16, data []byte) ([]byte, bool) {
+	switch method {
+	case 0: // stored
+		return data, true
+	case 8: // deflate
+		fr := flate.NewReader(bytes.NewReader(data))
+		defer fr.Close()
+
+		out, err := io.ReadAll(fr)
+		if err != nil {
+			return nil, false
+		}
+		return out, true
+	default:
+		return nil, false
+	}
+}
+
+// contentTypeContains reports whether [Content_Types].xml contains substr,
+// used to tell OOXML subtypes apart (e.g. template vs. regular documents)
+// that share the same part names.
+func contentTypeContains(buf []byte, substr string) bool {
+	data, ok := zipReadFile(buf, []byte("[Content_Types].xml"))
+	if !ok {
+		return false
+	}
+
+	return bytes.Contains(data, []byte(substr))
+}
+
+// zipReadFileReader is zipReadFile for an io.ReaderAt: it reads only the
+// central directory and the one entry asked for, regardless of the
+// archive's overall size.
+func zipReadFileReader(r io.ReaderAt, size int64, name []byte) ([]byte, bool) {
+	entries, ok := zipCentralDirEntriesReader(r, size)
+	if !ok {
+		return nil, false
+	}
+
+	for _, e := range entries {
+		if bytes.Equal(e.name, name) {
+			return readLocalFileDataReader(r, size, e.localHeaderOffset, e.compressedSize)
+		}
+	}
+
+	return nil, false
+}
+
+func readLocalFileDataReader(r io.ReaderAt, size int64, localHeaderOffset, cdCompressedSize uint32) ([]byte, bool) {
+	off := int64(localHeaderOffset)
+	if off < 0 || off+30 > size {
+		return nil, false
+	}
+
+	head := make([]byte, 30)
+	if _, err := r.ReadAt(head, off); err != nil && err != io.EOF {
+		return nil, false
+	}
+	if binary.LittleEndian.Uint32(head) != zipLocalFileHeaderSig {
+		return nil, false
+	}
+
+	flags := binary.LittleEndian.Uint16(head[6:])
+	method := binary.LittleEndian.Uint16(head[8:])
+	compSize := int64(binary.LittleEndian.Uint32(head[18:]))
+	if flags&zipDataDescriptor != 0 {
+		compSize = int64(cdCompressedSize)
+	}
+	nameLen := int64(binary.LittleEndian.Uint16(head[26:]))
+	extraLen := int64(binary.LittleEndian.Uint16(head[28:]))
+
+	dataStart := off + 30 + nameLen + extraLen
+	dataEnd := dataStart + compSize
+	if dataEnd > size {
+		return nil, false
+	}
+
+	data := make([]byte, compSize)
+	if _, err := r.ReadAt(data, dataStart); err != nil && err != io.EOF {
+		return nil, false
+	}
+
+	return decompress(method, data)
+}
+
+// contentTypeContainsReader is contentTypeContains for an io.ReaderAt.
+func contentTypeContainsReader(r io.ReaderAt, size int64, substr string) bool {
+	data, ok := zipReadFileReader(r, size, []byte("[Content_Types].xml"))
+	if !ok {
+		return false
+	}
+
+	return bytes.Contains(data, []byte(substr))
+}